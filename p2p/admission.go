@@ -0,0 +1,52 @@
+/**
+ * File        : admission.go
+ * Description : Per-peer admission control for inbound pairing requests.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import (
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+// tooBusy reports whether a peer already has too large a backlog queued,
+// per MaxQueuedArtifactsPerPeer / MaxOutstandingBytesPerPeer, and if so,
+// why.  A peer's queue only exists while it's paired (dropStream removes
+// the queue along with the stream), so this always reports false for a
+// peer that isn't paired.  pairHandler consults it only after rejecting
+// already-paired peers, so there it finds no queue except in the moment
+// between dropStream removing the stream and removing the queue; the
+// limits take effect through Send, which consults it on every enqueue.
+func (client *client) tooBusy(pid peer.ID) (string, bool) {
+	queue := client.queueFor(pid)
+	if queue == nil {
+		return "", false
+	}
+
+	count, bytes := queue.Outstanding()
+	if limit := client.config.MaxQueuedArtifactsPerPeer; limit > 0 && count > limit {
+		return "too many queued artifacts", true
+	}
+	if limit := client.config.MaxOutstandingBytesPerPeer; limit > 0 && bytes > limit {
+		return "too many outstanding bytes", true
+	}
+	return "", false
+}
+
+// strike records an admission-control violation against a peer, banning it
+// and removing it from the routing table once it accrues too many within
+// the configured window.
+func (client *client) strike(pid peer.ID) {
+	banned := client.streamstore.Strike(
+		pid,
+		client.config.StrikeLimit,
+		client.config.StrikeWindow,
+		client.config.BanDuration,
+	)
+	if banned {
+		client.table.Remove(pid)
+	}
+}
@@ -0,0 +1,47 @@
+/**
+ * File        : client.go
+ * Description : The client type shared by the peer-to-peer services.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import (
+	"context"
+	"sync"
+
+	"github.com/enzoh/go-logging"
+	"gx/ipfs/QmNpMprzKNTVAZoH9TxDDxEivJwXnyj1odacUCjHAs3XTZ/go-libp2p-host"
+	"gx/ipfs/QmPgDWmTmuzvP7QE5zwo1TmjbJme9pmZHNujB2453jkCTr/go-libp2p-peerstore"
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+
+	"github.com/dfinity/go-revolver/routingtable"
+)
+
+// client exchanges artifacts with peers over paired streams.
+type client struct {
+	sync.Mutex
+
+	context  context.Context
+	host     host.Host
+	protocol string
+	config   *Config
+	logger   logging.Logger
+
+	peerstore   peerstore.Peerstore
+	table       routingtable.RoutingTable
+	streamstore *streamstore
+
+	// queues holds the outbound priority queue for each paired peer.
+	queues map[peer.ID]*priorityQueue
+
+	// persistent holds the peers that should be automatically reconnected
+	// if pairing with them fails or drops.
+	persistent map[peer.ID]bool
+
+	// reconnecting tracks the peers that already have a reconnect
+	// supervisor running, so pair() doesn't spawn a second one.
+	reconnecting map[peer.ID]bool
+}
@@ -0,0 +1,58 @@
+/**
+ * File        : config.go
+ * Description : Configuration for the peer-to-peer client.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import "time"
+
+// Config configures a client.
+type Config struct {
+
+	// Timeout bounds how long to wait for I/O on a stream.
+	Timeout time.Duration
+
+	// ReconnectBackoffBase is the initial delay before retrying a dropped
+	// persistent-peer pairing.
+	ReconnectBackoffBase time.Duration
+
+	// ReconnectBackoffCap bounds how large the retry delay can grow before
+	// jitter is applied.
+	ReconnectBackoffCap time.Duration
+
+	// ReconnectBackoffJitter is the fraction (0 to 1) of the backoff delay
+	// to randomize, to avoid retry storms against the same peer.
+	ReconnectBackoffJitter float64
+
+	// MaxQueuedArtifactsPerPeer bounds how many artifacts a peer may have
+	// queued for delivery, across all priority levels: once a peer's
+	// backlog exceeds it, Send refuses further artifacts for that peer and
+	// records a strike.  A new pairing attempt is not turned away by it,
+	// since a peer's queue is removed when its stream drops.  Zero means no
+	// limit.
+	MaxQueuedArtifactsPerPeer int
+
+	// MaxOutstandingBytesPerPeer bounds how many bytes of queued-but-unsent
+	// artifacts a peer may have outstanding.  Zero means no limit.
+	MaxOutstandingBytesPerPeer int
+
+	// MaxInboundPairsPerIPPrefix bounds how many inbound pairings may be
+	// accepted at once from the same /24 (IPv4) or /48 (IPv6) prefix. Zero
+	// means no limit.
+	MaxInboundPairsPerIPPrefix int
+
+	// StrikeLimit is how many admission-control violations a peer may
+	// accrue within StrikeWindow before it's banned and removed from the
+	// routing table.
+	StrikeLimit int
+
+	// StrikeWindow is the sliding window strikes are counted over.
+	StrikeWindow time.Duration
+
+	// BanDuration is how long a peer is banned after hitting StrikeLimit.
+	BanDuration time.Duration
+}
@@ -0,0 +1,68 @@
+/**
+ * File        : ipprefix.go
+ * Description : Network-prefix grouping for inbound-pairing admission control.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import (
+	stdnet "net"
+	"strings"
+
+	"gx/ipfs/QmNa31VPzC561NWwRsJLE7nGYZYuuD2QfpK2b1q9BK54J1/go-libp2p-net"
+)
+
+// ipPrefix returns the /24 (IPv4) or /48 (IPv6) network prefix of a
+// stream's remote address, for inbound-pairing admission control.  It
+// returns "" if the address can't be determined.
+func ipPrefix(stream net.Stream) string {
+	addr := stream.Conn().RemoteMultiaddr()
+	if addr == nil {
+		return ""
+	}
+
+	parts := strings.Split(addr.String(), "/")
+	for i, part := range parts {
+		switch part {
+		case "ip4":
+			if i+1 < len(parts) {
+				return ipv4Prefix(parts[i+1])
+			}
+		case "ip6":
+			if i+1 < len(parts) {
+				return ipv6Prefix(parts[i+1])
+			}
+		}
+	}
+	return ""
+}
+
+// ipv4Prefix returns the /24 network of an IPv4 address.
+func ipv4Prefix(ip string) string {
+	octets := strings.Split(ip, ".")
+	if len(octets) != 4 {
+		return ""
+	}
+	return strings.Join(octets[:3], ".") + ".0/24"
+}
+
+// ipv6Prefix returns the /48 network of an IPv6 address.  It parses the
+// address rather than splitting on ":" so that zero-compressed forms (e.g.
+// "2001:db8::1", or any address whose /48 itself contains a zero hextet,
+// such as link-local "fe80::" or ULA "fc00::") are handled correctly --
+// those are exactly the canonical forms net.IP.String() (what multiaddr
+// uses) produces.
+func ipv6Prefix(ip string) string {
+	parsed := stdnet.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	network := parsed.Mask(stdnet.CIDRMask(48, 128))
+	if network == nil {
+		return ""
+	}
+	return network.String() + "/48"
+}
@@ -0,0 +1,34 @@
+package p2p
+
+import "testing"
+
+func TestIpv4Prefix(t *testing.T) {
+	cases := map[string]string{
+		"192.168.1.42": "192.168.1.0/24",
+		"10.0.0.1":     "10.0.0.0/24",
+		"not-an-ip":    "",
+		"1.2.3":        "",
+	}
+	for in, want := range cases {
+		if got := ipv4Prefix(in); got != want {
+			t.Errorf("ipv4Prefix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIpv6Prefix(t *testing.T) {
+	cases := map[string]string{
+		// Zero-compressed forms must expand before masking, not split on ":".
+		"2001:db8::1":     "2001:db8::/48",
+		"2001:db8:1:2::1": "2001:db8:1::/48",
+		"fe80::1":         "fe80::/48",
+		"fc00::1":         "fc00::/48",
+		"::1":             "::/48",
+		"not-an-ip":       "",
+	}
+	for in, want := range cases {
+		if got := ipv6Prefix(in); got != want {
+			t.Errorf("ipv6Prefix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -39,7 +39,14 @@ func (client *client) pair(peerId peer.ID) (bool, error) {
 		addrs := client.peerstore.PeerInfo(pid).Addrs
 		client.logger.Debug("Cannot connect to", pid, "at", addrs, err)
 		client.peerstore.ClearAddrs(pid)
-		client.table.Remove(pid)
+		if client.IsPersistent(pid) {
+			// Keep the peer in the routing table and let the supervisor
+			// retry with backoff, rather than dropping it on the first
+			// failure.
+			go client.superviseReconnect(pid)
+		} else {
+			client.table.Remove(pid)
+		}
 		return false, err
 	}
 
@@ -57,10 +64,11 @@ func (client *client) pair(peerId peer.ID) (bool, error) {
 
 	// Add the outbound stream to the stream store.
 	var success bool
-	if data[0] == ack && client.streamstore.Add(pid, stream, true) {
+	if data[0] == ack && client.streamstore.Add(pid, stream, true, "") {
 
 		// Ready to send artifacts.
 		client.logger.Debug("Ready to exchange artifacts with", pid)
+		client.newQueueFor(pid)
 		go client.process(stream)
 		success = true
 
@@ -99,8 +107,31 @@ func (client *client) pairHandler(stream net.Stream) {
 		stream.Close()
 	}
 
-	// Add the inbound stream to the stream store.
-	if !client.streamstore.Add(pid, stream, false) {
+	// Check the temporary ban list before doing anything else.
+	if client.streamstore.IsBanned(pid) {
+		reject(pid, " is temporarily banned")
+		return
+	}
+
+	// A peer that's already paired gets a plain rejection, not a strike:
+	// this is a redundant request, not misbehavior, and streamstore.Add
+	// would reject it anyway.
+	if client.streamstore.IsPaired(pid) {
+		reject(pid, " is already paired")
+		return
+	}
+
+	// Turn away a peer that's still sitting on too large a backlog.
+	if reason, overLimit := client.tooBusy(pid); overLimit {
+		client.strike(pid)
+		reject(pid, " ", reason)
+		return
+	}
+
+	// Add the inbound stream to the stream store, subject to the inbound
+	// pairs per IP prefix admission limit.
+	if !client.streamstore.Add(pid, stream, false, ipPrefix(stream)) {
+		client.strike(pid)
 		reject(pid, " cannot be added to the stream store")
 		return
 	}
@@ -113,12 +144,13 @@ func (client *client) pairHandler(stream net.Stream) {
 	)
 	if err != nil {
 		client.logger.Warning("Cannot send data to", pid, err)
-		client.streamstore.Remove(pid)
+		client.dropStream(pid)
 		return
 	}
 
 	// Ready to exchange artifacts.
 	client.logger.Debug("Ready to exchange artifacts with", pid)
+	client.newQueueFor(pid)
 	go client.process(stream)
 	return
 
@@ -0,0 +1,45 @@
+/**
+ * File        : process.go
+ * Description : Drains a paired stream's priority queue.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import (
+	"gx/ipfs/QmNa31VPzC561NWwRsJLE7nGYZYuuD2QfpK2b1q9BK54J1/go-libp2p-net"
+
+	"github.com/dfinity/go-revolver/util"
+)
+
+// process drains a paired stream's priority queue -- Top down to Low -- and
+// writes each artifact out in that order, so latency-sensitive control
+// artifacts (heartbeats, votes) can preempt bulk data sharing the same
+// connection.  It returns once the queue is closed and drained, or a write
+// fails.
+//
+// Note this only orders the send side; a peer sharing this stream across
+// multiple protocols would need the read side prioritized too, but nothing
+// in this client multiplexes protocols over a single paired stream yet.
+func (client *client) process(stream net.Stream) {
+	pid := stream.Conn().RemotePeer()
+	queue := client.queueFor(pid)
+	if queue == nil {
+		return
+	}
+
+	for {
+		artifact, _, ok := queue.pop()
+		if !ok {
+			return
+		}
+		err := util.WriteWithTimeout(stream, artifact, client.config.Timeout)
+		if err != nil {
+			client.logger.Warning("Cannot send data to", pid, err)
+			client.dropStream(pid)
+			return
+		}
+	}
+}
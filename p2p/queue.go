@@ -0,0 +1,203 @@
+/**
+ * File        : queue.go
+ * Description : Priority queues for artifacts awaiting delivery to a peer.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import (
+	"fmt"
+	"sync"
+
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+// Priority is the relative urgency of an artifact queued for sending over a
+// paired stream.  process drains the highest-priority level first, so
+// latency-sensitive control artifacts (heartbeats, votes) can preempt bulk
+// data sharing the same connection.
+type Priority uint8
+
+const (
+	Low Priority = iota
+	Mid
+	High
+	Top
+)
+
+const priorityLevels = int(Top) + 1
+
+// maxQueuedPerLevel bounds how many artifacts may sit at a single priority
+// level before the oldest one is dropped to make room.
+const maxQueuedPerLevel = 4096
+
+// QueueCounters reports how many artifacts have been enqueued, sent, and
+// dropped at a single priority level, for metrics reporting.
+type QueueCounters struct {
+	Enqueued uint64
+	Sent     uint64
+	Dropped  uint64
+}
+
+// priorityQueue is a per-stream, multi-level FIFO queue of artifacts
+// awaiting delivery.
+type priorityQueue struct {
+	sync.Mutex
+	levels   [priorityLevels][][]byte
+	counters [priorityLevels]QueueCounters
+	ready    chan struct{}
+	closed   bool
+}
+
+func newPriorityQueue() *priorityQueue {
+	return &priorityQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push enqueues an artifact at the given priority, dropping the
+// least-recently-queued artifact at that level if it's already full.
+func (q *priorityQueue) push(priority Priority, artifact []byte) {
+	q.Lock()
+	level := &q.levels[priority]
+	counters := &q.counters[priority]
+	if len(*level) >= maxQueuedPerLevel {
+		// Nil the vacated head slot before reslicing, so the dropped
+		// artifact isn't kept alive through the backing array until a
+		// later append happens to reallocate it.
+		(*level)[0] = nil
+		*level = (*level)[1:]
+		counters.Dropped++
+	}
+	*level = append(*level, artifact)
+	counters.Enqueued++
+	q.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes and returns the next artifact to send, highest priority
+// first.  It blocks until one is available or the queue is closed and
+// drained.
+func (q *priorityQueue) pop() ([]byte, Priority, bool) {
+	for {
+		q.Lock()
+		for level := priorityLevels - 1; level >= 0; level-- {
+			if len(q.levels[level]) > 0 {
+				artifact := q.levels[level][0]
+				// Nil the vacated head slot before reslicing, so the sent
+				// artifact isn't kept alive through the backing array.
+				q.levels[level][0] = nil
+				q.levels[level] = q.levels[level][1:]
+				q.counters[level].Sent++
+				q.Unlock()
+				return artifact, Priority(level), true
+			}
+		}
+		closed := q.closed
+		q.Unlock()
+		if closed {
+			return nil, 0, false
+		}
+		<-q.ready
+	}
+}
+
+// close wakes up a blocked pop and marks the queue as closed once drained.
+func (q *priorityQueue) close() {
+	q.Lock()
+	q.closed = true
+	q.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Counters returns a snapshot of the queue's per-level counters.
+func (q *priorityQueue) Counters() [priorityLevels]QueueCounters {
+	q.Lock()
+	defer q.Unlock()
+	return q.counters
+}
+
+// Outstanding returns the total number of queued artifacts and their
+// combined size in bytes, across all priority levels.
+func (q *priorityQueue) Outstanding() (count int, bytes int) {
+	q.Lock()
+	defer q.Unlock()
+	for _, level := range q.levels {
+		count += len(level)
+		for _, artifact := range level {
+			bytes += len(artifact)
+		}
+	}
+	return count, bytes
+}
+
+// queueFor returns the priority queue for a paired peer, or nil if the peer
+// isn't currently paired.
+func (client *client) queueFor(pid peer.ID) *priorityQueue {
+	client.Lock()
+	defer client.Unlock()
+	return client.queues[pid]
+}
+
+// newQueueFor creates and registers a priority queue for a newly paired
+// peer.
+func (client *client) newQueueFor(pid peer.ID) *priorityQueue {
+	client.Lock()
+	defer client.Unlock()
+	if client.queues == nil {
+		client.queues = make(map[peer.ID]*priorityQueue)
+	}
+	queue := newPriorityQueue()
+	client.queues[pid] = queue
+	return queue
+}
+
+// removeQueueFor closes and drops a peer's priority queue.
+func (client *client) removeQueueFor(pid peer.ID) {
+	client.Lock()
+	queue := client.queues[pid]
+	delete(client.queues, pid)
+	client.Unlock()
+	if queue != nil {
+		queue.close()
+	}
+}
+
+// QueueCounters returns a snapshot of a paired peer's per-level queue
+// counters, for metrics reporting.
+func (client *client) QueueCounters(pid peer.ID) [priorityLevels]QueueCounters {
+	queue := client.queueFor(pid)
+	if queue == nil {
+		return [priorityLevels]QueueCounters{}
+	}
+	return queue.Counters()
+}
+
+// Send enqueues an artifact to be sent to a paired peer at the given
+// priority.  It returns an error if the peer isn't currently paired, or if
+// the peer's backlog already exceeds MaxQueuedArtifactsPerPeer /
+// MaxOutstandingBytesPerPeer -- this is where those limits actually gate a
+// live peer's backlog, rather than only at the pre-pairing admission
+// check.
+func (client *client) Send(pid peer.ID, artifact []byte, priority Priority) error {
+	queue := client.queueFor(pid)
+	if queue == nil {
+		return fmt.Errorf("not paired with %s", pid)
+	}
+	if reason, overLimit := client.tooBusy(pid); overLimit {
+		client.strike(pid)
+		return fmt.Errorf("not sending to %s: %s", pid, reason)
+	}
+	queue.push(priority, artifact)
+	return nil
+}
@@ -0,0 +1,74 @@
+package p2p
+
+import "testing"
+
+func TestPriorityQueuePopHighestFirst(t *testing.T) {
+	q := newPriorityQueue()
+	q.push(Low, []byte("low"))
+	q.push(Top, []byte("top"))
+	q.push(Mid, []byte("mid"))
+
+	for _, want := range []Priority{Top, Mid, Low} {
+		artifact, priority, ok := q.pop()
+		if !ok {
+			t.Fatalf("expected an artifact at priority %v", want)
+		}
+		if priority != want {
+			t.Fatalf("expected priority %v, got %v (artifact %q)", want, priority, artifact)
+		}
+	}
+}
+
+func TestPriorityQueuePushDropsOldestOnOverflow(t *testing.T) {
+	q := newPriorityQueue()
+	for i := 0; i < maxQueuedPerLevel+1; i++ {
+		q.push(Low, []byte{byte(i)})
+	}
+
+	counters := q.Counters()
+	if counters[Low].Dropped != 1 {
+		t.Fatalf("expected 1 dropped artifact, got %d", counters[Low].Dropped)
+	}
+	if counters[Low].Enqueued != uint64(maxQueuedPerLevel+1) {
+		t.Fatalf("expected %d enqueued, got %d", maxQueuedPerLevel+1, counters[Low].Enqueued)
+	}
+
+	artifact, _, ok := q.pop()
+	if !ok {
+		t.Fatalf("expected an artifact")
+	}
+	if artifact[0] != 1 {
+		t.Fatalf("expected the oldest artifact (0) to be dropped and the next (1) to come first, got %v", artifact[0])
+	}
+}
+
+func TestPriorityQueueOutstanding(t *testing.T) {
+	q := newPriorityQueue()
+	q.push(Low, []byte("abc"))
+	q.push(High, []byte("de"))
+
+	count, bytes := q.Outstanding()
+	if count != 2 {
+		t.Fatalf("expected 2 outstanding artifacts, got %d", count)
+	}
+	if bytes != 5 {
+		t.Fatalf("expected 5 outstanding bytes, got %d", bytes)
+	}
+
+	// pop drains High before Low, so "de" is what's dequeued here.
+	q.pop()
+	count, bytes = q.Outstanding()
+	if count != 1 || bytes != 3 {
+		t.Fatalf("expected 1 outstanding artifact / 3 bytes after pop, got %d/%d", count, bytes)
+	}
+}
+
+func TestPriorityQueueCloseUnblocksPop(t *testing.T) {
+	q := newPriorityQueue()
+	q.close()
+
+	artifact, priority, ok := q.pop()
+	if ok {
+		t.Fatalf("expected pop on a closed, empty queue to report !ok, got %q at priority %v", artifact, priority)
+	}
+}
@@ -0,0 +1,143 @@
+/**
+ * File        : reconnect.go
+ * Description : Automatic reconnection for persistent peers.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import (
+	"math/rand"
+	"time"
+
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+// PairPersistent pairs with a peer and marks it persistent, so the client
+// will keep retrying if the pairing fails or later drops.
+func (client *client) PairPersistent(peerId peer.ID) (bool, error) {
+	client.Lock()
+	if client.persistent == nil {
+		client.persistent = make(map[peer.ID]bool)
+	}
+	client.persistent[peerId] = true
+	client.Unlock()
+
+	ok, err := client.pair(peerId)
+	if !ok && !client.streamstore.IsPaired(peerId) {
+		// pair only starts a supervisor itself when it can't open a stream;
+		// cover a pairing that failed later on, or that the peer rejected.
+		go client.superviseReconnect(peerId)
+	}
+	return ok, err
+}
+
+// IsPersistent reports whether a peer is marked persistent.
+func (client *client) IsPersistent(peerId peer.ID) bool {
+	client.Lock()
+	defer client.Unlock()
+	return client.persistent[peerId]
+}
+
+// UnpairPersistent clears a peer's persistent status.  Any reconnect
+// supervisor running for it exits on its next retry.
+func (client *client) UnpairPersistent(peerId peer.ID) {
+	client.Lock()
+	defer client.Unlock()
+	delete(client.persistent, peerId)
+}
+
+// dropStream removes a peer's stream from the stream store and, if the peer
+// is persistent, starts a supervisor to reconnect it.
+func (client *client) dropStream(peerId peer.ID) {
+	client.streamstore.Remove(peerId)
+	client.removeQueueFor(peerId)
+	if client.IsPersistent(peerId) {
+		go client.superviseReconnect(peerId)
+	}
+}
+
+// superviseReconnect retries pairing with a persistent peer, backing off
+// exponentially between attempts, until it succeeds, the peer is unpaired,
+// or the client shuts down.  At most one supervisor runs per peer at a
+// time.
+func (client *client) superviseReconnect(peerId peer.ID) {
+	client.Lock()
+	if client.reconnecting == nil {
+		client.reconnecting = make(map[peer.ID]bool)
+	}
+	if client.reconnecting[peerId] {
+		client.Unlock()
+		return
+	}
+	client.reconnecting[peerId] = true
+	client.Unlock()
+
+	defer func() {
+		client.Lock()
+		delete(client.reconnecting, peerId)
+		client.Unlock()
+	}()
+
+	var attempt uint
+	for {
+		if !client.IsPersistent(peerId) {
+			return
+		}
+
+		delay := reconnectBackoff(
+			attempt,
+			client.config.ReconnectBackoffBase,
+			client.config.ReconnectBackoffCap,
+			client.config.ReconnectBackoffJitter,
+		)
+		client.logger.Debug("Waiting", delay, "to retry pairing with", peerId)
+
+		select {
+		case <-time.After(delay):
+		case <-client.context.Done():
+			return
+		}
+
+		if !client.IsPersistent(peerId) {
+			return
+		}
+
+		if ok, _ := client.pair(peerId); ok {
+			return
+		}
+		attempt++
+	}
+}
+
+// reconnectBackoff computes the delay before the given retry attempt
+// (0-indexed), growing exponentially from base up to cap and randomized by
+// jitter (a fraction of the delay, 0 to 1) to avoid retry storms.
+func reconnectBackoff(attempt uint, base, cap time.Duration, jitter float64) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+	if cap <= 0 {
+		cap = time.Minute
+	}
+
+	delay := base
+	if attempt < 32 {
+		delay = base * time.Duration(uint64(1)<<attempt)
+	}
+	if delay <= 0 || delay > cap {
+		delay = cap
+	}
+
+	if jitter > 0 {
+		spread := float64(delay) * jitter
+		delay += time.Duration(spread*rand.Float64() - spread/2)
+		if delay < 0 {
+			delay = 0
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,44 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoffGrowsAndCaps(t *testing.T) {
+	base := 100 * time.Millisecond
+	cap := time.Second
+
+	prev := time.Duration(0)
+	for attempt := uint(0); attempt < 10; attempt++ {
+		delay := reconnectBackoff(attempt, base, cap, 0)
+		if delay < prev {
+			t.Fatalf("attempt %d: backoff %v is less than previous %v", attempt, delay, prev)
+		}
+		if delay > cap {
+			t.Fatalf("attempt %d: backoff %v exceeds cap %v", attempt, delay, cap)
+		}
+		prev = delay
+	}
+	if got := reconnectBackoff(9, base, cap, 0); got != cap {
+		t.Fatalf("expected backoff to have saturated at the cap by attempt 9, got %v", got)
+	}
+}
+
+func TestReconnectBackoffJitterStaysNonNegative(t *testing.T) {
+	base := 100 * time.Millisecond
+	cap := time.Second
+	for attempt := uint(0); attempt < 20; attempt++ {
+		delay := reconnectBackoff(attempt, base, cap, 1)
+		if delay < 0 {
+			t.Fatalf("attempt %d: jittered backoff went negative: %v", attempt, delay)
+		}
+	}
+}
+
+func TestReconnectBackoffDefaultsForNonPositiveBaseAndCap(t *testing.T) {
+	delay := reconnectBackoff(0, 0, 0, 0)
+	if delay != time.Second {
+		t.Fatalf("expected default base of 1s for attempt 0, got %v", delay)
+	}
+}
@@ -0,0 +1,139 @@
+/**
+ * File        : streamstore.go
+ * Description : A registry of the streams paired with each peer, with
+ *               admission control against inbound-pairing floods.
+ * Copyright   : Copyright (c) 2017-2018 DFINITY Stiftung. All rights reserved.
+ * Maintainer  : Enzo Haussecker <enzo@dfinity.org>
+ * Stability   : Experimental
+ */
+
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"gx/ipfs/QmNa31VPzC561NWwRsJLE7nGYZYuuD2QfpK2b1q9BK54J1/go-libp2p-net"
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+// streamstore tracks the stream paired with each peer, plus enough inbound
+// bookkeeping (pairs per IP prefix, strikes, bans) to keep a single
+// misbehaving peer from flooding pair requests.
+type streamstore struct {
+	sync.Mutex
+
+	streams map[peer.ID]net.Stream
+
+	// maxInboundPerPrefix bounds concurrent inbound pairs sharing a /24
+	// (IPv4) or /48 (IPv6) prefix.  Zero means no limit.
+	maxInboundPerPrefix int
+	inboundByPrefix     map[string]int
+	prefixOf            map[peer.ID]string
+
+	strikes map[peer.ID][]time.Time
+	banned  map[peer.ID]time.Time
+}
+
+// newStreamstore creates an empty streamstore.
+func newStreamstore(maxInboundPerPrefix int) *streamstore {
+	return &streamstore{
+		streams:             make(map[peer.ID]net.Stream),
+		maxInboundPerPrefix: maxInboundPerPrefix,
+		inboundByPrefix:     make(map[string]int),
+		prefixOf:            make(map[peer.ID]string),
+		strikes:             make(map[peer.ID][]time.Time),
+		banned:              make(map[peer.ID]time.Time),
+	}
+}
+
+// Add registers a stream for a peer.  It returns false if the peer is
+// already paired, or if accepting an inbound stream would exceed
+// maxInboundPerPrefix for the given prefix (ignored for outbound streams).
+func (store *streamstore) Add(pid peer.ID, stream net.Stream, outbound bool, prefix string) bool {
+	store.Lock()
+	defer store.Unlock()
+
+	if _, ok := store.streams[pid]; ok {
+		return false
+	}
+
+	if !outbound && prefix != "" && store.maxInboundPerPrefix > 0 &&
+		store.inboundByPrefix[prefix] >= store.maxInboundPerPrefix {
+		return false
+	}
+
+	store.streams[pid] = stream
+	if !outbound && prefix != "" {
+		store.inboundByPrefix[prefix]++
+		store.prefixOf[pid] = prefix
+	}
+	return true
+}
+
+// Remove unregisters a peer's stream.
+func (store *streamstore) Remove(pid peer.ID) {
+	store.Lock()
+	defer store.Unlock()
+	delete(store.streams, pid)
+
+	if prefix, ok := store.prefixOf[pid]; ok {
+		store.inboundByPrefix[prefix]--
+		if store.inboundByPrefix[prefix] <= 0 {
+			delete(store.inboundByPrefix, prefix)
+		}
+		delete(store.prefixOf, pid)
+	}
+}
+
+// IsPaired reports whether a peer already has a stream registered.
+func (store *streamstore) IsPaired(pid peer.ID) bool {
+	store.Lock()
+	defer store.Unlock()
+	_, ok := store.streams[pid]
+	return ok
+}
+
+// IsBanned reports whether a peer is currently within its temporary ban
+// window.
+func (store *streamstore) IsBanned(pid peer.ID) bool {
+	store.Lock()
+	defer store.Unlock()
+	until, ok := store.banned[pid]
+	if !ok {
+		return false
+	}
+	if !time.Now().Before(until) {
+		delete(store.banned, pid)
+		return false
+	}
+	return true
+}
+
+// Strike records an admission-control violation against a peer.  It
+// returns true once the peer has accrued `limit` strikes within `window`,
+// in which case it's banned for `ban`.
+func (store *streamstore) Strike(pid peer.ID, limit int, window, ban time.Duration) bool {
+	store.Lock()
+	defer store.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-window)
+
+	var recent []time.Time
+	for _, t := range store.strikes[pid] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+
+	if limit > 0 && len(recent) >= limit {
+		delete(store.strikes, pid)
+		store.banned[pid] = now.Add(ban)
+		return true
+	}
+
+	store.strikes[pid] = recent
+	return false
+}
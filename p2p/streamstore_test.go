@@ -0,0 +1,95 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+func TestStreamstoreAddRejectsAlreadyPaired(t *testing.T) {
+	store := newStreamstore(0)
+	pid := peer.ID("a")
+
+	if !store.Add(pid, nil, true, "") {
+		t.Fatalf("expected first Add to succeed")
+	}
+	if store.Add(pid, nil, true, "") {
+		t.Fatalf("expected second Add for an already-paired peer to fail")
+	}
+}
+
+func TestStreamstoreAddEnforcesInboundPrefixLimit(t *testing.T) {
+	store := newStreamstore(1)
+	a, b := peer.ID("a"), peer.ID("b")
+
+	if !store.Add(a, nil, false, "10.0.0.0/24") {
+		t.Fatalf("expected first inbound pairing on the prefix to succeed")
+	}
+	if store.Add(b, nil, false, "10.0.0.0/24") {
+		t.Fatalf("expected second inbound pairing on a full prefix to fail")
+	}
+
+	// Outbound streams don't consume the inbound prefix budget.
+	if !store.Add(b, nil, true, "10.0.0.0/24") {
+		t.Fatalf("expected outbound Add to ignore the inbound prefix limit")
+	}
+}
+
+func TestStreamstoreRemoveFreesPrefixSlot(t *testing.T) {
+	store := newStreamstore(1)
+	a, b := peer.ID("a"), peer.ID("b")
+
+	store.Add(a, nil, false, "10.0.0.0/24")
+	store.Remove(a)
+
+	if !store.Add(b, nil, false, "10.0.0.0/24") {
+		t.Fatalf("expected removing a to free up the prefix slot for b")
+	}
+}
+
+func TestStreamstoreStrikeBansAfterLimit(t *testing.T) {
+	store := newStreamstore(0)
+	pid := peer.ID("a")
+
+	if store.Strike(pid, 3, time.Minute, time.Hour) {
+		t.Fatalf("expected first strike to not trigger a ban")
+	}
+	if store.Strike(pid, 3, time.Minute, time.Hour) {
+		t.Fatalf("expected second strike to not trigger a ban")
+	}
+	if !store.Strike(pid, 3, time.Minute, time.Hour) {
+		t.Fatalf("expected third strike to trigger a ban")
+	}
+	if !store.IsBanned(pid) {
+		t.Fatalf("expected peer to be banned after hitting the strike limit")
+	}
+}
+
+func TestStreamstoreStrikeWindowExpiry(t *testing.T) {
+	store := newStreamstore(0)
+	pid := peer.ID("a")
+
+	// Strikes older than `window` shouldn't count toward the limit, so a
+	// strike recorded far in the past doesn't carry into a later check.
+	store.Lock()
+	store.strikes[pid] = []time.Time{time.Now().Add(-time.Hour)}
+	store.Unlock()
+
+	if store.Strike(pid, 2, time.Minute, time.Hour) {
+		t.Fatalf("expected a stale strike outside the window to not count toward the limit")
+	}
+}
+
+func TestStreamstoreIsBannedExpires(t *testing.T) {
+	store := newStreamstore(0)
+	pid := peer.ID("a")
+
+	store.Lock()
+	store.banned[pid] = time.Now().Add(-time.Second)
+	store.Unlock()
+
+	if store.IsBanned(pid) {
+		t.Fatalf("expected an expired ban to report false")
+	}
+}
@@ -0,0 +1,363 @@
+package routingtable
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+// defaultReplacementCacheSize bounds the number of peers held in reserve for
+// a bucket, in case its least-recently-seen entry needs replacing.
+const defaultReplacementCacheSize = 8
+
+// defaultProbeTimeout bounds how long a single liveness probe of a bucket's
+// least-recently-seen peer may take.
+const defaultProbeTimeout = 5 * time.Second
+
+// defaultBucketSize is used in place of a caller-supplied bucketSize that
+// isn't positive, matching the classic Kademlia k=20.
+const defaultBucketSize = 20
+
+// kadID is the keyspace identifier used for XOR-distance comparisons.  Peer
+// IDs are hashed down to a fixed-width, uniformly distributed identifier,
+// following the same approach as libp2p-kad-dht.
+type kadID [sha256.Size]byte
+
+// convertPeerID hashes a peer ID into its keyspace identifier.
+func convertPeerID(pid peer.ID) kadID {
+	return sha256.Sum256([]byte(pid))
+}
+
+// commonPrefixLen returns the number of leading bits shared between a and b.
+func commonPrefixLen(a, b kadID) int {
+	for i := range a {
+		x := a[i] ^ b[i]
+		if x == 0 {
+			continue
+		}
+		for bit := 0; bit < 8; bit++ {
+			if x&(0x80>>uint(bit)) != 0 {
+				return i*8 + bit
+			}
+		}
+	}
+	return len(a) * 8
+}
+
+// kadBucketEntry records a peer and the last time it was seen, so buckets
+// can order their members from least- to most-recently-seen.
+type kadBucketEntry struct {
+	id       peer.ID
+	lastSeen time.Time
+}
+
+// kadBucket holds up to `bucketSize` peers sharing a given common-prefix-
+// length with the local peer, plus an optional replacement cache of peers
+// waiting to take the place of an entry that turns out to be unreachable.
+type kadBucket struct {
+	peers       *list.List // of *kadBucketEntry, front = least-recently-seen
+	replacement *list.List // of *kadBucketEntry
+}
+
+func newKadBucket() *kadBucket {
+	return &kadBucket{
+		peers:       list.New(),
+		replacement: list.New(),
+	}
+}
+
+// tryAdd inserts or refreshes a peer in the bucket without blocking on a
+// liveness probe.  If the bucket already holds pid, or has room for it, the
+// peer is inserted/refreshed and inserted is true.  Otherwise the bucket is
+// full of other peers: tryAdd leaves it untouched and returns its
+// least-recently-seen entry as hasCandidate's eviction candidate, for the
+// caller to probe outside the table lock.
+func (b *kadBucket) tryAdd(pid peer.ID, bucketSize int) (inserted bool, candidate peer.ID, hasCandidate bool) {
+	for e := b.peers.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*kadBucketEntry)
+		if entry.id == pid {
+			entry.lastSeen = time.Now()
+			b.peers.MoveToBack(e)
+			return true, "", false
+		}
+	}
+
+	if b.peers.Len() < bucketSize {
+		b.peers.PushBack(&kadBucketEntry{id: pid, lastSeen: time.Now()})
+		return true, "", false
+	}
+
+	front := b.peers.Front()
+	if front == nil {
+		// bucketSize isn't positive: there's neither room for pid nor
+		// anything to evict in its favor.
+		return false, "", false
+	}
+	return false, front.Value.(*kadBucketEntry).id, true
+}
+
+// resolveAdd commits the outcome of probing a tryAdd eviction candidate.
+// The table lock was released for the probe, so the bucket may have
+// changed in the meantime (pid or candidate may have been added/removed by
+// another call); resolveAdd re-checks before committing rather than
+// assuming its view of the bucket is still accurate.
+func (b *kadBucket) resolveAdd(pid, candidate peer.ID, candidateAlive bool, bucketSize int) {
+	if inserted, _, _ := b.tryAdd(pid, bucketSize); inserted {
+		return
+	}
+
+	for e := b.peers.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*kadBucketEntry)
+		if entry.id != candidate {
+			continue
+		}
+		if candidateAlive {
+			// The candidate is still alive; keep it and hold the new peer
+			// in reserve.
+			entry.lastSeen = time.Now()
+			b.peers.MoveToBack(e)
+			b.addReplacement(pid)
+		} else {
+			b.peers.Remove(e)
+			b.peers.PushBack(&kadBucketEntry{id: pid, lastSeen: time.Now()})
+		}
+		return
+	}
+
+	// The candidate is gone too (raced with a concurrent Remove); retry the
+	// cheap path once more now that the bucket has changed shape, and give
+	// up quietly if it's still full of other, newer entries.
+	b.tryAdd(pid, bucketSize)
+}
+
+// addReplacement stashes a peer in the replacement cache, bounded to
+// defaultReplacementCacheSize entries, dropping the oldest on overflow.
+func (b *kadBucket) addReplacement(pid peer.ID) {
+	for e := b.replacement.Front(); e != nil; e = e.Next() {
+		if e.Value.(*kadBucketEntry).id == pid {
+			return
+		}
+	}
+	b.replacement.PushBack(&kadBucketEntry{id: pid, lastSeen: time.Now()})
+	if b.replacement.Len() > defaultReplacementCacheSize {
+		b.replacement.Remove(b.replacement.Front())
+	}
+}
+
+// remove drops a peer from the bucket, promoting the most-recently-seen
+// replacement (if any) to take its place.
+func (b *kadBucket) remove(pid peer.ID) {
+	for e := b.peers.Front(); e != nil; e = e.Next() {
+		if e.Value.(*kadBucketEntry).id == pid {
+			b.peers.Remove(e)
+			if r := b.replacement.Back(); r != nil {
+				b.replacement.Remove(r)
+				b.peers.PushBack(r.Value.(*kadBucketEntry))
+			}
+			return
+		}
+	}
+}
+
+// list returns the peers in the bucket, except those in `exclude`.
+func (b *kadBucket) list(exclude map[peer.ID]bool) []peer.ID {
+	var peers []peer.ID
+	for e := b.peers.Front(); e != nil; e = e.Next() {
+		id := e.Value.(*kadBucketEntry).id
+		if !exclude[id] {
+			peers = append(peers, id)
+		}
+	}
+	return peers
+}
+
+func (b *kadBucket) len() int {
+	return b.peers.Len()
+}
+
+// kadRoutingTable is a RoutingTable that organizes peers into k-buckets by
+// XOR distance from the local peer ID, as done in libp2p-kad-dht.  It's a
+// better fit than ringsRoutingTable when callers need locality in keyspace
+// (DHT lookups, content addressing) rather than in RTT.
+type kadRoutingTable struct {
+	sync.RWMutex
+
+	localID    peer.ID
+	localKey   kadID
+	bucketSize int
+	latency    LatencyProbeFn
+
+	// buckets[i] holds peers whose keyspace identifier shares exactly i
+	// leading bits with localKey.
+	buckets []*kadBucket
+}
+
+// NewKadRoutingTable creates a RoutingTable that buckets peers by XOR
+// distance from localID.  Each bucket holds at most bucketSize peers, and
+// latency is used to probe a bucket's least-recently-seen peer before
+// evicting it in favor of a new one.
+func NewKadRoutingTable(localID peer.ID, bucketSize int, latency LatencyProbeFn) RoutingTable {
+	if bucketSize <= 0 {
+		bucketSize = defaultBucketSize
+	}
+	buckets := make([]*kadBucket, sha256.Size*8+1)
+	for i := range buckets {
+		buckets[i] = newKadBucket()
+	}
+	return &kadRoutingTable{
+		localID:    localID,
+		localKey:   convertPeerID(localID),
+		bucketSize: bucketSize,
+		latency:    latency,
+		buckets:    buckets,
+	}
+}
+
+// bucketIndex returns the bucket that pid belongs in, relative to the local
+// peer.
+func (t *kadRoutingTable) bucketIndex(pid peer.ID) int {
+	cpl := commonPrefixLen(t.localKey, convertPeerID(pid))
+	if cpl >= len(t.buckets) {
+		cpl = len(t.buckets) - 1
+	}
+	return cpl
+}
+
+func (t *kadRoutingTable) Add(pid peer.ID) {
+	if pid == t.localID {
+		return
+	}
+	idx := t.bucketIndex(pid)
+	bucket := t.buckets[idx]
+
+	t.Lock()
+	inserted, candidate, hasCandidate := bucket.tryAdd(pid, t.bucketSize)
+	t.Unlock()
+	if inserted || !hasCandidate {
+		return
+	}
+
+	// Probe the eviction candidate without holding the table lock, so a
+	// single slow or unresponsive peer can't stall every other
+	// Add/Remove/Recommend/RecommendNear/Size call on the table for up to
+	// defaultProbeTimeout.  Same reasoning as ringsRoutingTable.Add.
+	var alive bool
+	if t.latency != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultProbeTimeout)
+		_, err := t.latency(ctx, candidate)
+		cancel()
+		alive = err == nil
+	}
+
+	t.Lock()
+	bucket.resolveAdd(pid, candidate, alive, t.bucketSize)
+	t.Unlock()
+}
+
+func (t *kadRoutingTable) Remove(pid peer.ID) {
+	idx := t.bucketIndex(pid)
+
+	t.Lock()
+	defer t.Unlock()
+	t.buckets[idx].remove(pid)
+}
+
+func (t *kadRoutingTable) Recommend(count int, excludeList []peer.ID) []peer.ID {
+	exclude := toExcludeSet(excludeList)
+
+	t.RLock()
+	defer t.RUnlock()
+
+	var all []peer.ID
+	for _, bucket := range t.buckets {
+		all = append(all, bucket.list(exclude)...)
+	}
+
+	perm := rand.Perm(len(all))
+	var recommended []peer.ID
+	for i := 0; i < len(perm) && len(recommended) < count; i++ {
+		recommended = append(recommended, all[perm[i]])
+	}
+	return recommended
+}
+
+// RecommendNear recommends up to `count` peers close to `target` in
+// keyspace, by walking outward from the bucket closest to target and
+// sorting the candidates it collects by true XOR distance.
+func (t *kadRoutingTable) RecommendNear(target []byte, count int, excludeList []peer.ID) []peer.ID {
+	var targetKey kadID
+	copy(targetKey[:], target)
+	exclude := toExcludeSet(excludeList)
+
+	t.RLock()
+	defer t.RUnlock()
+
+	start := commonPrefixLen(t.localKey, targetKey)
+	if start >= len(t.buckets) {
+		start = len(t.buckets) - 1
+	}
+
+	var candidates []peer.ID
+	for lo, hi := start, start+1; lo >= 0 || hi < len(t.buckets); lo, hi = lo-1, hi+1 {
+		if lo >= 0 {
+			candidates = append(candidates, t.buckets[lo].list(exclude)...)
+		}
+		if hi < len(t.buckets) {
+			candidates = append(candidates, t.buckets[hi].list(exclude)...)
+		}
+		if len(candidates) >= count {
+			break
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return xorDistanceLess(candidates[i], candidates[j], targetKey)
+	})
+
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+	return candidates
+}
+
+// xorDistanceLess reports whether a is closer to target than b.
+func xorDistanceLess(a, b peer.ID, target kadID) bool {
+	da := convertPeerID(a)
+	db := convertPeerID(b)
+	for i := range target {
+		xa := da[i] ^ target[i]
+		xb := db[i] ^ target[i]
+		if xa != xb {
+			return xa < xb
+		}
+	}
+	return false
+}
+
+func toExcludeSet(excludeList []peer.ID) map[peer.ID]bool {
+	exclude := make(map[peer.ID]bool, len(excludeList))
+	for _, pid := range excludeList {
+		exclude[pid] = true
+	}
+	return exclude
+}
+
+func (t *kadRoutingTable) Size() int {
+	t.RLock()
+	defer t.RUnlock()
+	var size int
+	for _, bucket := range t.buckets {
+		size += bucket.len()
+	}
+	return size
+}
+
+// Shutdown is a no-op: kadRoutingTable has no background goroutines.
+func (t *kadRoutingTable) Shutdown() {
+}
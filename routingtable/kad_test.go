@@ -0,0 +1,105 @@
+package routingtable
+
+import (
+	"testing"
+
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+func TestKadBucketAddRefreshAndRemove(t *testing.T) {
+	b := newKadBucket()
+	a, c := peer.ID("a"), peer.ID("c")
+
+	inserted, _, hasCandidate := b.tryAdd(a, 2)
+	if !inserted || hasCandidate {
+		t.Fatalf("expected a to be inserted with no eviction candidate")
+	}
+
+	// Re-adding a already-present peer refreshes it rather than requiring
+	// an eviction candidate.
+	inserted, _, hasCandidate = b.tryAdd(a, 2)
+	if !inserted || hasCandidate {
+		t.Fatalf("expected refresh of a to report inserted with no eviction candidate")
+	}
+	if b.len() != 1 {
+		t.Fatalf("expected 1 peer after refresh, got %d", b.len())
+	}
+
+	b.remove(a)
+	if b.len() != 0 {
+		t.Fatalf("expected 0 peers after remove, got %d", b.len())
+	}
+	_ = c
+}
+
+func TestKadBucketTryAddReturnsCandidateWhenFull(t *testing.T) {
+	b := newKadBucket()
+	a, c := peer.ID("a"), peer.ID("c")
+
+	if inserted, _, _ := b.tryAdd(a, 1); !inserted {
+		t.Fatalf("expected a to be inserted into an empty bucket")
+	}
+
+	inserted, candidate, hasCandidate := b.tryAdd(c, 1)
+	if inserted {
+		t.Fatalf("expected c to not be inserted into a full bucket")
+	}
+	if !hasCandidate || candidate != a {
+		t.Fatalf("expected eviction candidate a, got %v (hasCandidate=%v)", candidate, hasCandidate)
+	}
+	if b.len() != 1 {
+		t.Fatalf("tryAdd must not mutate the bucket when returning a candidate, got len %d", b.len())
+	}
+}
+
+func TestKadBucketResolveAddKeepsAliveCandidate(t *testing.T) {
+	b := newKadBucket()
+	a, c := peer.ID("a"), peer.ID("c")
+	b.tryAdd(a, 1)
+
+	b.resolveAdd(c, a, true /* candidateAlive */, 1)
+
+	if b.len() != 1 {
+		t.Fatalf("expected bucket to still hold 1 peer, got %d", b.len())
+	}
+	front := b.peers.Front().Value.(*kadBucketEntry)
+	if front.id != a {
+		t.Fatalf("expected alive candidate a to be kept, got %v", front.id)
+	}
+	if b.replacement.Len() != 1 {
+		t.Fatalf("expected new peer c to be stashed in the replacement cache")
+	}
+}
+
+func TestKadBucketResolveAddEvictsDeadCandidate(t *testing.T) {
+	b := newKadBucket()
+	a, c := peer.ID("a"), peer.ID("c")
+	b.tryAdd(a, 1)
+
+	b.resolveAdd(c, a, false /* candidateAlive */, 1)
+
+	if b.len() != 1 {
+		t.Fatalf("expected bucket to still hold 1 peer, got %d", b.len())
+	}
+	front := b.peers.Front().Value.(*kadBucketEntry)
+	if front.id != c {
+		t.Fatalf("expected dead candidate a to be evicted in favor of c, got %v", front.id)
+	}
+}
+
+func TestKadBucketRemovePromotesReplacement(t *testing.T) {
+	b := newKadBucket()
+	a, c := peer.ID("a"), peer.ID("c")
+	b.tryAdd(a, 1)
+	b.addReplacement(c)
+
+	b.remove(a)
+
+	if b.len() != 1 {
+		t.Fatalf("expected replacement to be promoted, got %d peers", b.len())
+	}
+	front := b.peers.Front().Value.(*kadBucketEntry)
+	if front.id != c {
+		t.Fatalf("expected promoted replacement c, got %v", front.id)
+	}
+}
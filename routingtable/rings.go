@@ -1,6 +1,7 @@
 package routingtable
 
 import (
+	"context"
 	"math/rand"
 	"sync"
 	"time"
@@ -10,8 +11,10 @@ import (
 	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
 )
 
-// LatencyProbeFn is a function that accepts a peer ID and returns a latency.
-type LatencyProbeFn func(peer.ID) (time.Duration, error)
+// LatencyProbeFn is a function that accepts a context and a peer ID and
+// returns a latency.  It should respect ctx's deadline/cancellation so a
+// stalled peer can't block whoever is waiting on it.
+type LatencyProbeFn func(ctx context.Context, pid peer.ID) (time.Duration, error)
 
 // RingsConfig configures a Ring-based routing table
 type RingsConfig struct {
@@ -20,10 +23,19 @@ type RingsConfig struct {
 	LatencyGrowthFactor float64
 	SampleSize          int
 	SamplePeriod        time.Duration
+
+	// ProbeTimeout bounds how long a single latency probe may take, so a
+	// stalled peer can't hold up an entire sample cycle.
+	ProbeTimeout time.Duration
+
 	// A function for retrieving the up-to-date latency information for a given
 	// peer.
 	LatencyProbFn LatencyProbeFn
 
+	// Selector decides how Recommend divides a request for `count` peers
+	// across the rings.  Defaults to UniformSelector.
+	Selector RingSelector
+
 	Logger logging.Logger
 }
 
@@ -47,43 +59,72 @@ type ringsRoutingTable struct {
 	// For storing latency info
 	metrics peerstore.Metrics
 
-	// Shutdown signal
-	shutdown chan struct{}
+	// ctx governs the lifetime of the maintenance loop and bounds probes
+	// issued outside of it (e.g. from Add); cancel ends both.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-// A ring stores a list of peers within a certain latency range
+// A ring stores a set of peers within a certain latency range.  peers and
+// index are kept in lockstep so Add/Remove are O(1): index maps a peer to
+// its position in peers, and Remove uses the swap-with-last trick instead
+// of shifting the slice.
 type ring struct {
 	peers []peer.ID
+	index map[peer.ID]int
 }
 
-// Add a peer to the ring
+// Add a peer to the ring.
 func (r *ring) Add(pid peer.ID) {
+	if r.index == nil {
+		r.index = make(map[peer.ID]int)
+	}
+	if _, ok := r.index[pid]; ok {
+		return
+	}
+	r.index[pid] = len(r.peers)
 	r.peers = append(r.peers, pid)
 }
 
-// Remove a peer from the ring
+// Remove a peer from the ring.
 func (r *ring) Remove(pid peer.ID) {
-	for i, peer := range r.peers {
-		if peer == pid {
-			r.peers = append(r.peers[:i], r.peers[i+1:]...)
-		}
+	i, ok := r.index[pid]
+	if !ok {
+		return
 	}
+
+	last := len(r.peers) - 1
+	r.peers[i] = r.peers[last]
+	r.index[r.peers[i]] = i
+	r.peers = r.peers[:last]
+	delete(r.index, pid)
 }
 
-// Return `count` random peers in the ring, except for those in the `exclude`
-// list.
+// Recommend returns up to `count` random peers in the ring, except for
+// those in `exclude`, using reservoir sampling so a hot-path call doesn't
+// need to allocate a full permutation of the ring.
 func (r *ring) Recommend(count int, exclude map[peer.ID]bool) []peer.ID {
-	var recommended []peer.ID
+	if count <= 0 {
+		return nil
+	}
 
-	perm := rand.Perm(len(r.peers))
-	for i := 0; i < count && i < len(perm); i++ {
-		pid := r.peers[perm[i]]
-		if !exclude[pid] {
-			recommended = append(recommended, pid)
+	reservoir := make([]peer.ID, 0, count)
+	var seen int
+	for _, pid := range r.peers {
+		if exclude[pid] {
+			continue
+		}
+		seen++
+		if len(reservoir) < count {
+			reservoir = append(reservoir, pid)
+			continue
+		}
+		if j := rand.Intn(seen); j < count {
+			reservoir[j] = pid
 		}
 	}
 
-	return recommended
+	return reservoir
 }
 
 // NewDefaultRingsConfig creates a RingsConfig with default parameters.
@@ -94,12 +135,15 @@ func NewDefaultRingsConfig(probe LatencyProbeFn) RingsConfig {
 		LatencyGrowthFactor: 2,
 		SampleSize:          16,
 		SamplePeriod:        30 * time.Second,
+		ProbeTimeout:        5 * time.Second,
 		LatencyProbFn:       probe,
+		Selector:            UniformSelector{},
 	}
 }
 
-// NewRingsRoutingTable creates a RoutingTable with the given config.
-func NewRingsRoutingTable(conf RingsConfig) RoutingTable {
+// NewRingsRoutingTable creates a RoutingTable with the given config.  The
+// maintenance loop and any probes it issues exit when ctx is done.
+func NewRingsRoutingTable(ctx context.Context, conf RingsConfig) RoutingTable {
 	// Construct the latency ranges
 	// The first element is always going to be 0.
 	latRanges := []time.Duration{time.Duration(0)}
@@ -114,24 +158,30 @@ func NewRingsRoutingTable(conf RingsConfig) RoutingTable {
 		rings = append(rings, &ring{})
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+
 	r := &ringsRoutingTable{
 		conf:      conf,
 		rings:     rings,
 		peers:     make(map[peer.ID]bool),
 		metrics:   peerstore.NewMetrics(),
 		latRanges: latRanges,
-		shutdown:  make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 
-	// Periodically refresh latency and re-balance rings until explicitly shut
-	// down.
+	// Periodically refresh latency and re-balance rings until ctx is done.
 	go func() {
-		select {
-		case <-time.After(r.conf.SamplePeriod):
-			r.refreshLatency()
-			r.populateRings()
-		case <-r.shutdown:
-			return
+		for {
+			select {
+			case <-time.After(r.conf.SamplePeriod):
+				cycleCtx, cancelCycle := context.WithTimeout(ctx, r.conf.SamplePeriod)
+				r.refreshLatency(cycleCtx)
+				r.populateRings(cycleCtx)
+				cancelCycle()
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
@@ -139,8 +189,10 @@ func NewRingsRoutingTable(conf RingsConfig) RoutingTable {
 }
 
 // refreshLatency picks a random subset of peers and refresh their latency
-// information.  The rings are then re-populated.
-func (r *ringsRoutingTable) refreshLatency() {
+// information.  The rings are then re-populated.  ctx bounds the whole
+// sample cycle; each individual probe additionally gets its own
+// ProbeTimeout.
+func (r *ringsRoutingTable) refreshLatency(ctx context.Context) {
 	var pids []peer.ID
 	var peerCount int
 	func() {
@@ -162,21 +214,35 @@ func (r *ringsRoutingTable) refreshLatency() {
 	}
 
 	for _, pid := range sample {
-		latency, err := r.conf.LatencyProbFn(pid)
+		if ctx.Err() != nil {
+			return
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, r.conf.ProbeTimeout)
+		latency, err := r.conf.LatencyProbFn(probeCtx, pid)
+		cancel()
 		if err != nil {
 			r.conf.Logger.Errorf("error probing latency of peer %v", pid)
 		} else {
 			func() {
 				r.Lock()
 				defer r.Unlock()
-				r.metrics.RecordLatency(pid, latency)
+				// The peer may have been removed while its probe was in
+				// flight; don't resurrect it in the metrics store.
+				if r.peers[pid] {
+					r.metrics.RecordLatency(pid, latency)
+				}
 			}()
 		}
 	}
 }
 
 // populateRings puts peers into the rings.
-func (r *ringsRoutingTable) populateRings() {
+func (r *ringsRoutingTable) populateRings(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+
 	r.Lock()
 	defer r.Unlock()
 
@@ -211,7 +277,9 @@ func (r *ringsRoutingTable) Add(pid peer.ID) {
 
 	// Otherwise, ping it and record latency info.
 	// Note how we don't want to hold the lock while pinging it.
-	latency, err := r.conf.LatencyProbFn(pid)
+	ctx, cancel := context.WithTimeout(r.ctx, r.conf.ProbeTimeout)
+	defer cancel()
+	latency, err := r.conf.LatencyProbFn(ctx, pid)
 	if err != nil {
 		r.conf.Logger.Errorf("Error probing peer %s", pid)
 		return
@@ -228,10 +296,10 @@ func (r *ringsRoutingTable) Remove(pid peer.ID) {
 	r.Lock()
 	defer r.Unlock()
 	delete(r.peers, pid)
+	r.metrics.RemoveLatency(pid)
 	for _, ring := range r.rings {
 		ring.Remove(pid)
 	}
-	// TODO: remove the peer from the metrics store too
 }
 
 func (r *ringsRoutingTable) Recommend(count int, excludeList []peer.ID) []peer.ID {
@@ -245,21 +313,11 @@ func (r *ringsRoutingTable) Recommend(count int, excludeList []peer.ID) []peer.I
 	}
 
 	// Compute how many nodes we want from each ring
-	nodesFromRing := make([]int, r.conf.RingsCount)
-
-	// TODO: if count is less than the number of rings, we actually want to
-	// select from rings that are evenly spaced out.  For instance, if count is
-	// 3 and we have 9 rings, then we want to select from the 0th, the 3rd, and
-	// the 6th ring.
-	var j int // index for ring
-	for i := 0; i < count; i++ {
-		nodesFromRing[j]++
-		j++
-		if j >= r.conf.RingsCount {
-			// Reset index
-			j = 0
-		}
+	selector := r.conf.Selector
+	if selector == nil {
+		selector = UniformSelector{}
 	}
+	nodesFromRing := selector.Select(count, r.conf.RingsCount, r.latRanges)
 
 	var recommended []peer.ID
 	for i, count := range nodesFromRing {
@@ -281,6 +339,12 @@ func (r *ringsRoutingTable) Recommend(count int, excludeList []peer.ID) []peer.I
 	return recommended
 }
 
+// RecommendNear recommends peers close to `target` in keyspace.  Rings have
+// no notion of keyspace locality, so this simply falls back to Recommend.
+func (r *ringsRoutingTable) RecommendNear(target []byte, count int, exclude []peer.ID) []peer.ID {
+	return r.Recommend(count, exclude)
+}
+
 // Return a random sample of peers, except those in the `exclude` set
 func (r *ringsRoutingTable) sample(count int, exclude map[peer.ID]bool) []peer.ID {
 	var peers []peer.ID
@@ -304,5 +368,5 @@ func (r *ringsRoutingTable) Size() int {
 }
 
 func (r *ringsRoutingTable) Shutdown() {
-	close(r.shutdown)
+	r.cancel()
 }
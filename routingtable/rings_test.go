@@ -0,0 +1,68 @@
+package routingtable
+
+import (
+	"testing"
+
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+func TestRingAddRemove(t *testing.T) {
+	r := &ring{}
+
+	a, b, c := peer.ID("a"), peer.ID("b"), peer.ID("c")
+	r.Add(a)
+	r.Add(b)
+	r.Add(c)
+	if len(r.peers) != 3 {
+		t.Fatalf("expected 3 peers, got %d", len(r.peers))
+	}
+
+	// Adding a peer already in the ring must not duplicate it.
+	r.Add(b)
+	if len(r.peers) != 3 {
+		t.Fatalf("expected 3 peers after re-add, got %d", len(r.peers))
+	}
+
+	// Remove the middle entry and check the swap-with-last bookkeeping
+	// left the ring internally consistent.
+	r.Remove(b)
+	if len(r.peers) != 2 {
+		t.Fatalf("expected 2 peers after remove, got %d", len(r.peers))
+	}
+	if _, ok := r.index[b]; ok {
+		t.Fatalf("removed peer %v still present in index", b)
+	}
+	for pid, i := range r.index {
+		if r.peers[i] != pid {
+			t.Fatalf("index out of sync: index[%v] = %d, peers[%d] = %v", pid, i, i, r.peers[i])
+		}
+	}
+
+	// Removing an unknown peer is a no-op.
+	r.Remove(peer.ID("nope"))
+	if len(r.peers) != 2 {
+		t.Fatalf("expected 2 peers after removing unknown peer, got %d", len(r.peers))
+	}
+}
+
+func TestRingRecommendExcludes(t *testing.T) {
+	r := &ring{}
+	a, b, c := peer.ID("a"), peer.ID("b"), peer.ID("c")
+	r.Add(a)
+	r.Add(b)
+	r.Add(c)
+
+	recommended := r.Recommend(10, map[peer.ID]bool{b: true})
+	if len(recommended) != 2 {
+		t.Fatalf("expected 2 recommended peers, got %d", len(recommended))
+	}
+	for _, pid := range recommended {
+		if pid == b {
+			t.Fatalf("excluded peer %v was recommended", b)
+		}
+	}
+
+	if got := r.Recommend(0, nil); got != nil {
+		t.Fatalf("expected no recommendations for count 0, got %v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package routingtable
+
+import (
+	"gx/ipfs/QmXYjuNuxVzXKJCfWasQk1RqkhVLDM9jtUKhqc2WPQmFSB/go-libp2p-peer"
+)
+
+// RoutingTable recommends peers to communicate with, based on some notion of
+// distance between peers (e.g. latency or XOR distance in keyspace).
+type RoutingTable interface {
+
+	// Add a peer to the routing table.
+	Add(pid peer.ID)
+
+	// Remove a peer from the routing table.
+	Remove(pid peer.ID)
+
+	// Recommend up to `count` peers, excluding those in `exclude`.
+	Recommend(count int, exclude []peer.ID) []peer.ID
+
+	// RecommendNear recommends up to `count` peers close to `target` in
+	// keyspace, excluding those in `exclude`.  Implementations with no
+	// notion of keyspace locality may fall back to Recommend.
+	RecommendNear(target []byte, count int, exclude []peer.ID) []peer.ID
+
+	// Size returns the number of peers known to the routing table.
+	Size() int
+
+	// Shutdown terminates any background routines owned by the routing
+	// table.
+	Shutdown()
+}
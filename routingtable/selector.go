@@ -0,0 +1,143 @@
+package routingtable
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RingSelector decides how many of the `count` peers requested from a
+// Recommend call should be drawn from each ring, given the rings' latency
+// ranges.  This lets operators trade discovery breadth for latency
+// locality: a selector biased toward the inner (low-latency) rings favors
+// nearby peers, while Uniform spreads the request evenly.
+type RingSelector interface {
+
+	// Select returns a slice of length ringsCount, where the ith entry is
+	// how many peers to draw from the ith ring to satisfy `count` total.
+	Select(count, ringsCount int, latRanges []time.Duration) []int
+}
+
+// UniformSelector spreads the requested count evenly across the rings,
+// round-robin style.  This is the original ringsRoutingTable.Recommend
+// behavior.
+type UniformSelector struct{}
+
+func (UniformSelector) Select(count, ringsCount int, latRanges []time.Duration) []int {
+	nodesFromRing := make([]int, ringsCount)
+	if ringsCount == 0 {
+		return nodesFromRing
+	}
+
+	var j int
+	for i := 0; i < count; i++ {
+		nodesFromRing[j]++
+		j++
+		if j >= ringsCount {
+			j = 0
+		}
+	}
+	return nodesFromRing
+}
+
+// LatencyWeightedSelector samples ring i with probability proportional to
+// 1 / (latRanges[i] + Epsilon), so nearby rings are favored without
+// excluding the rest entirely.
+type LatencyWeightedSelector struct {
+	// Epsilon avoids a divide-by-zero for the innermost ring, whose
+	// latency range starts at 0.  Defaults to 1ms if unset.
+	Epsilon time.Duration
+}
+
+func (s LatencyWeightedSelector) Select(count, ringsCount int, latRanges []time.Duration) []int {
+	eps := s.Epsilon
+	if eps <= 0 {
+		eps = time.Millisecond
+	}
+
+	weights := make([]float64, ringsCount)
+	for i := 0; i < ringsCount; i++ {
+		weights[i] = 1 / (float64(latRanges[i]) + float64(eps))
+	}
+
+	return sampleWeighted(count, weights)
+}
+
+// ExponentialSelector samples ring i with probability proportional to
+// Alpha^i, so each successive ring is favored Alpha times less than the
+// one before it.
+type ExponentialSelector struct {
+	// Alpha must be in (0, 1); defaults to 0.5 if unset.
+	Alpha float64
+}
+
+func (s ExponentialSelector) Select(count, ringsCount int, latRanges []time.Duration) []int {
+	alpha := s.Alpha
+	if alpha <= 0 || alpha >= 1 {
+		alpha = 0.5
+	}
+
+	weights := make([]float64, ringsCount)
+	p := 1.0
+	for i := 0; i < ringsCount; i++ {
+		weights[i] = p
+		p *= alpha
+	}
+
+	return sampleWeighted(count, weights)
+}
+
+// StratifiedSelector picks evenly-spaced rings when fewer peers are
+// requested than there are rings, rather than clustering the draw in the
+// first few rings.  When count is at least ringsCount, it falls back to
+// UniformSelector.
+type StratifiedSelector struct{}
+
+func (StratifiedSelector) Select(count, ringsCount int, latRanges []time.Duration) []int {
+	nodesFromRing := make([]int, ringsCount)
+	if ringsCount == 0 || count <= 0 {
+		return nodesFromRing
+	}
+	if count >= ringsCount {
+		return UniformSelector{}.Select(count, ringsCount, latRanges)
+	}
+
+	for k := 0; k < count; k++ {
+		idx := int(math.Round(float64(k) * float64(ringsCount) / float64(count)))
+		if idx >= ringsCount {
+			idx = ringsCount - 1
+		}
+		nodesFromRing[idx]++
+	}
+	return nodesFromRing
+}
+
+// sampleWeighted draws `count` ring indices independently according to
+// `weights` (proportional, need not sum to 1), and returns how many draws
+// landed on each ring.
+func sampleWeighted(count int, weights []float64) []int {
+	nodesFromRing := make([]int, len(weights))
+
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return nodesFromRing
+	}
+
+	for i := 0; i < count; i++ {
+		target := rand.Float64() * total
+		var cum float64
+		idx := len(weights) - 1
+		for j, w := range weights {
+			cum += w
+			if target < cum {
+				idx = j
+				break
+			}
+		}
+		nodesFromRing[idx]++
+	}
+	return nodesFromRing
+}